@@ -0,0 +1,94 @@
+package config
+
+import "time"
+
+// BastionFlag enables bastion (jump host) mode, where the destination of a
+// TCP/websocket request is carried in a header rather than fixed by the
+// ingress rule.
+const BastionFlag = "bastion"
+
+// UnvalidatedIngressRule is the raw, user-authored form of an ingress rule
+// as parsed from the tunnel's YAML/JSON configuration, before hostname
+// globs and origin services have been resolved.
+type UnvalidatedIngressRule struct {
+	Hostname string
+	Path     string
+	Service  string
+
+	OriginRequest OriginRequestConfig
+}
+
+// Configuration is the top level tunnel configuration.
+type Configuration struct {
+	TunnelID string
+	Ingress  []UnvalidatedIngressRule
+}
+
+// OriginRequestConfig holds per-rule settings that control how cloudflared
+// talks to the origin service. Zero values mean "use the global default".
+type OriginRequestConfig struct {
+	ConnectTimeout         *time.Duration `yaml:"connectTimeout,omitempty"`
+	NoTLSVerify            *bool          `yaml:"noTLSVerify,omitempty"`
+	DisableChunkedEncoding *bool          `yaml:"disableChunkedEncoding,omitempty"`
+
+	// Middlewares run, in order, around every request this rule proxies
+	// to its origin service.
+	Middlewares []MiddlewareConfig `yaml:"middlewares,omitempty"`
+
+	// Retries configures the circuit-breaker/retry policy applied to
+	// this rule's origin requests. Nil disables both.
+	Retries *RetryConfig `yaml:"retries,omitempty"`
+}
+
+// RetryConfig configures per-rule circuit-breaker and retry behavior for
+// origin requests.
+type RetryConfig struct {
+	// MaxRetries is how many additional attempts an idempotent request
+	// gets after its first failed attempt.
+	MaxRetries uint `yaml:"maxRetries,omitempty"`
+	// RetryBackoff is the delay before the first retry; each later
+	// retry doubles it, with jitter applied. Defaults to 100ms.
+	RetryBackoff *time.Duration `yaml:"retryBackoff,omitempty"`
+	// BreakerFailureThreshold is how many consecutive origin failures
+	// trip the breaker open. Zero disables the breaker.
+	BreakerFailureThreshold uint `yaml:"breakerFailureThreshold,omitempty"`
+	// BreakerCooldown is how long the breaker stays open before letting
+	// a single probe request through. Defaults to 30s.
+	BreakerCooldown *time.Duration `yaml:"breakerCooldown,omitempty"`
+}
+
+// MiddlewareConfig configures a single OriginMiddleware. Exactly one
+// field should be set; it identifies which middleware this entry
+// configures.
+type MiddlewareConfig struct {
+	HeaderRewrite       *HeaderRewriteConfig       `yaml:"headerRewrite,omitempty"`
+	JWTValidation       *JWTValidationConfig       `yaml:"jwtValidation,omitempty"`
+	BodySizeLimit       *BodySizeLimitConfig       `yaml:"bodySizeLimit,omitempty"`
+	ResponseCompression *ResponseCompressionConfig `yaml:"responseCompression,omitempty"`
+}
+
+// HeaderRewriteConfig injects or strips request headers before they reach
+// the origin.
+type HeaderRewriteConfig struct {
+	Set    map[string]string `yaml:"set,omitempty"`
+	Remove []string          `yaml:"remove,omitempty"`
+}
+
+// JWTValidationConfig requires a valid bearer JWT, signed by a key
+// published at JWKSURL, before a request reaches the origin.
+type JWTValidationConfig struct {
+	JWKSURL  string   `yaml:"jwksURL"`
+	Issuer   string   `yaml:"issuer,omitempty"`
+	Audience []string `yaml:"audience,omitempty"`
+}
+
+// BodySizeLimitConfig rejects request bodies larger than MaxBytes.
+type BodySizeLimitConfig struct {
+	MaxBytes int64 `yaml:"maxBytes"`
+}
+
+// ResponseCompressionConfig controls gzip/br negotiation for origin
+// responses that aren't already encoded.
+type ResponseCompressionConfig struct {
+	Disable bool `yaml:"disable,omitempty"`
+}
@@ -0,0 +1,48 @@
+package connection
+
+import (
+	"io"
+	"net/http"
+)
+
+// Type identifies the kind of traffic flowing over a single proxied
+// connection, so OriginProxy can pick the right transport logic.
+type Type int
+
+const (
+	TypeHTTP Type = iota
+	TypeWebsocket
+	TypeTCP
+	TypeUDP
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeHTTP:
+		return "http"
+	case TypeWebsocket:
+		return "websocket"
+	case TypeTCP:
+		return "tcp"
+	case TypeUDP:
+		return "udp"
+	default:
+		return "unknown"
+	}
+}
+
+// ResponseWriter is how OriginProxy streams a response back to the edge
+// connection. HTTP requests only write; streaming connection types (TCP,
+// websocket) also read from it.
+type ResponseWriter interface {
+	io.Writer
+	io.Reader
+	WriteRespHeaders(status int, header http.Header) error
+	WriteErrorResponse()
+}
+
+// OriginProxy proxies an edge request to the origin matching it, writing
+// the result to w.
+type OriginProxy interface {
+	Proxy(w ResponseWriter, req *http.Request, connectionType Type) error
+}
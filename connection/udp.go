@@ -0,0 +1,13 @@
+package connection
+
+import "time"
+
+// DatagramResponseWriter is implemented by ResponseWriters carrying a
+// TypeUDP connection. Unlike TCP, a UDP flow has no transport-level
+// notion of being closed, so the proxy enforces idle timeouts itself by
+// pushing read/write deadlines onto the writer.
+type DatagramResponseWriter interface {
+	ResponseWriter
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
@@ -0,0 +1,54 @@
+package connection
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const metricsNamespace = "cloudflared"
+
+var (
+	udpActiveFlows = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "udp",
+		Name:      "active_flows",
+		Help:      "Number of currently active UDP flows proxied to an origin",
+	})
+
+	udpFlowBytesTransferred = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "udp",
+			Name:      "flow_bytes_total",
+			Help:      "Total bytes transferred per UDP flow direction",
+		},
+		[]string{"direction"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(udpActiveFlows, udpFlowBytesTransferred)
+}
+
+// UDPFlowMetrics tracks byte counts for a single UDP flow's lifetime; the
+// values are folded into the package-level counters when the flow closes.
+type UDPFlowMetrics struct {
+	originToEdge uint64
+	edgeToOrigin uint64
+}
+
+func NewUDPFlowMetrics() *UDPFlowMetrics {
+	udpActiveFlows.Inc()
+	return &UDPFlowMetrics{}
+}
+
+func (m *UDPFlowMetrics) AddOriginToEdge(n int) {
+	m.originToEdge += uint64(n)
+}
+
+func (m *UDPFlowMetrics) AddEdgeToOrigin(n int) {
+	m.edgeToOrigin += uint64(n)
+}
+
+func (m *UDPFlowMetrics) Close() {
+	udpActiveFlows.Dec()
+	udpFlowBytesTransferred.WithLabelValues("origin_to_edge").Add(float64(m.originToEdge))
+	udpFlowBytesTransferred.WithLabelValues("edge_to_origin").Add(float64(m.edgeToOrigin))
+}
@@ -0,0 +1,5 @@
+package h2mux
+
+// CFJumpDestinationHeader is the header used by bastion-mode requests to
+// carry the address of the final hop the edge should connect to.
+const CFJumpDestinationHeader = "Cf-Cloudflared-Jump-Destination"
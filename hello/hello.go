@@ -0,0 +1,83 @@
+package hello
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// Route paths served by the built-in "hello-world" origin, used by
+// integration tests and `cloudflared tunnel --hello-world`.
+const (
+	HealthRoute = "/healthcheck"
+	WSRoute     = "/ws"
+	SSERoute    = "/sse"
+)
+
+// CreateMockOriginServer returns an http.Handler exercising the hello-world
+// routes above: a health check, a websocket echo endpoint, and a
+// server-sent-events stream that pushes an incrementing counter. Every
+// other path answers 200 with a short greeting.
+func CreateMockOriginServer() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("hello world"))
+	})
+	mux.HandleFunc(HealthRoute, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc(WSRoute, serveWS)
+	mux.HandleFunc(SSERoute, serveSSE)
+	return mux
+}
+
+// serveWS upgrades the connection and echoes back every message it
+// receives, preserving whether it was sent as a text or binary frame.
+func serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, _, _, err := ws.UpgradeHTTP(r, w)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		msg, op, err := wsutil.ReadClientData(conn)
+		if err != nil {
+			return
+		}
+		if err := wsutil.WriteServerMessage(conn, op, msg); err != nil {
+			return
+		}
+	}
+}
+
+func serveSSE(w http.ResponseWriter, r *http.Request) {
+	freq := 100 * time.Millisecond
+	if raw := r.URL.Query().Get("freq"); raw != "" {
+		if d, err := time.ParseDuration(raw); err == nil {
+			freq = d
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ticker := time.NewTicker(freq)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprintf(w, "%s\n\n", strconv.Itoa(i))
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package ingress
+
+import (
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker fast-fails origin requests after too many consecutive
+// failures, giving a struggling origin a cooldown window to recover
+// instead of being hammered with retries.
+type circuitBreaker struct {
+	failureThreshold uint
+	cooldown         time.Duration
+
+	mu               sync.Mutex
+	state            breakerState
+	consecutiveFails uint
+	openedAt         time.Time
+}
+
+func newCircuitBreaker(failureThreshold uint, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+	}
+}
+
+// allow reports whether a request may proceed to the origin. Once the
+// breaker has been open for the full cooldown, it lets exactly one probe
+// request through (half-open) to test whether the origin has recovered;
+// every other request is rejected until that probe resolves.
+func (b *circuitBreaker) allow() bool {
+	if b.failureThreshold == 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		return false
+	default: // breakerClosed
+		return true
+	}
+}
+
+func (b *circuitBreaker) recordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = breakerClosed
+	b.consecutiveFails = 0
+}
+
+func (b *circuitBreaker) recordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+		return
+	}
+
+	b.consecutiveFails++
+	if b.failureThreshold > 0 && b.consecutiveFails >= b.failureThreshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *circuitBreaker) State() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,155 @@
+package ingress
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/config"
+)
+
+// ServiceTeamnet is the ingress service name for a TCP-over-websocket
+// origin whose destination is resolved from the request at proxy time
+// (teamnet / bastion-less TCP routing).
+const ServiceTeamnet = "teamnet"
+
+const serviceHelloWorld = "hello-world"
+const httpStatusPrefix = "http_status:"
+
+// Ingress is the fully resolved set of rules a tunnel proxies traffic
+// through, in priority order. The last rule is conventionally a catch-all.
+type Ingress struct {
+	Rules []Rule
+}
+
+// ParseIngress validates and resolves raw ingress rules from the tunnel
+// configuration into an Ingress ready to serve traffic.
+func ParseIngress(conf *config.Configuration) (Ingress, error) {
+	if len(conf.Ingress) == 0 {
+		return Ingress{}, fmt.Errorf("no ingress rules were specified")
+	}
+
+	rules := make([]Rule, 0, len(conf.Ingress))
+	for i, r := range conf.Ingress {
+		isCatchAll := r.Hostname == "" || r.Hostname == "*"
+		if isCatchAll && i != len(conf.Ingress)-1 {
+			return Ingress{}, fmt.Errorf("rule %d is a catch-all rule but isn't the last rule", i)
+		}
+
+		service, err := resolveService(r.Service)
+		if err != nil {
+			return Ingress{}, err
+		}
+
+		middlewares, err := resolveMiddlewares(r.OriginRequest.Middlewares)
+		if err != nil {
+			return Ingress{}, fmt.Errorf("rule %d has an invalid middleware config: %w", i, err)
+		}
+
+		var path *regexp.Regexp
+		if r.Path != "" {
+			path, err = regexp.Compile(r.Path)
+			if err != nil {
+				return Ingress{}, fmt.Errorf("rule %d has an invalid path regex: %w", i, err)
+			}
+		}
+
+		rules = append(rules, Rule{
+			Hostname:    r.Hostname,
+			Path:        path,
+			Service:     service,
+			Middlewares: middlewares,
+			RetryPolicy: NewRetryPolicy(r.OriginRequest.Retries),
+		})
+	}
+	return Ingress{Rules: rules}, nil
+}
+
+func resolveService(service string) (OriginService, error) {
+	switch {
+	case service == serviceHelloWorld:
+		return &httpService{transport: http.DefaultTransport, helloworld: true}, nil
+	case service == ServiceTeamnet:
+		return &tcpOverWSService{}, nil
+	case strings.HasPrefix(service, httpStatusPrefix):
+		code, err := strconv.Atoi(strings.TrimPrefix(service, httpStatusPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s service %q: %w", httpStatusPrefix, service, err)
+		}
+		return &statusCodeService{code: code}, nil
+	case strings.HasPrefix(service, grpcServicePrefix):
+		return newGRPCService(service, nil)
+	case strings.HasPrefix(service, udpServicePrefix):
+		return newUDPService(service)
+	default:
+		return &httpService{transport: http.DefaultTransport, url: service}, nil
+	}
+}
+
+// NewSingleOrigin builds an Ingress with a single catch-all rule, either the
+// built-in hello-world test server or a bastion-mode TCP service,
+// depending on the CLI flags passed to cloudflared.
+func NewSingleOrigin(c *cli.Context, allowURLFromArgs bool) (Ingress, error) {
+	if c.Bool(config.BastionFlag) {
+		return Ingress{
+			Rules: []Rule{
+				{Hostname: "*", Service: &tcpOverWSService{bastion: true}},
+			},
+		}, nil
+	}
+	service, err := resolveService(serviceHelloWorld)
+	if err != nil {
+		return Ingress{}, err
+	}
+	return Ingress{
+		Rules: []Rule{
+			{Hostname: "*", Service: service},
+		},
+	}, nil
+}
+
+// StartOrigins starts every distinct origin service referenced by the
+// ingress rules, e.g. spinning up the local hello-world server.
+func (ing Ingress) StartOrigins(wg *sync.WaitGroup, log *zerolog.Logger, shutdownC <-chan struct{}, errC chan error) error {
+	for _, rule := range ing.Rules {
+		if err := rule.Service.Start(wg, log, shutdownC, errC); err != nil {
+			return fmt.Errorf("unable to start origin service for hostname %s: %w", rule.Hostname, err)
+		}
+	}
+	return nil
+}
+
+// FindMatchingRule returns the first rule whose hostname/path pattern
+// matches the request, or an error if no rule matches.
+func (ing Ingress) FindMatchingRule(hostname, path string) (*Rule, error) {
+	for i := range ing.Rules {
+		if ing.Rules[i].matches(hostname, path) {
+			return &ing.Rules[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no ingress rule matched %s%s", hostname, path)
+}
+
+// statusCodeService always responds with a fixed HTTP status code; it's
+// used for rules like `http_status:404` that don't proxy to a real origin.
+type statusCodeService struct {
+	code int
+}
+
+func (s *statusCodeService) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: s.code,
+		Body:       http.NoBody,
+		Header:     make(http.Header),
+	}, nil
+}
+
+func (s *statusCodeService) Start(wg *sync.WaitGroup, log *zerolog.Logger, shutdownC <-chan struct{}, errC chan error) error {
+	return nil
+}
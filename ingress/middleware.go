@@ -0,0 +1,68 @@
+package ingress
+
+import (
+	"net/http"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+// OriginMiddleware lets a Rule transform requests and responses around
+// the call to its origin service. A rule's middlewares run in the order
+// they were declared: every ProcessRequest runs before the origin is
+// invoked, then every ProcessResponse runs, in the same order, once the
+// origin has responded. ProcessResponse runs only for TypeHTTP requests;
+// TypeWebsocket and TypeTCP connections run ProcessRequest alone.
+type OriginMiddleware interface {
+	// Name identifies the middleware in logs and config validation errors.
+	Name() string
+
+	// AppliesTo reports whether this middleware runs for the given
+	// connection type. Most middlewares only make sense for HTTP-shaped
+	// traffic (TypeHTTP, TypeWebsocket); one that's meaningful for raw
+	// TCP streams too returns true for TypeTCP as well. None of the
+	// built-in middlewares apply to TypeTCP.
+	AppliesTo(connectionType connection.Type) bool
+
+	// ProcessRequest runs before the origin is called. Returning a
+	// non-nil response short-circuits the chain: neither the origin nor
+	// any later middleware's ProcessRequest runs. For TypeHTTP the chain
+	// moves straight to running ProcessResponse over that response; for
+	// other connection types its status and headers are written as they
+	// are.
+	ProcessRequest(req *http.Request) (*http.Response, error)
+
+	// ProcessResponse runs once a response exists, from the origin or
+	// from an earlier middleware's short-circuit, and may mutate it in
+	// place.
+	ProcessResponse(req *http.Request, resp *http.Response) error
+}
+
+// RunRequestMiddlewares runs ProcessRequest over middlewares that apply to
+// connType, in order, stopping at the first one that short-circuits with
+// its own response (or errors).
+func RunRequestMiddlewares(middlewares []OriginMiddleware, connType connection.Type, req *http.Request) (*http.Response, error) {
+	for _, m := range middlewares {
+		if !m.AppliesTo(connType) {
+			continue
+		}
+		resp, err := m.ProcessRequest(req)
+		if err != nil || resp != nil {
+			return resp, err
+		}
+	}
+	return nil, nil
+}
+
+// RunResponseMiddlewares runs ProcessResponse over middlewares that apply
+// to connType, in declaration order, stopping at the first error.
+func RunResponseMiddlewares(middlewares []OriginMiddleware, connType connection.Type, req *http.Request, resp *http.Response) error {
+	for _, m := range middlewares {
+		if !m.AppliesTo(connType) {
+			continue
+		}
+		if err := m.ProcessResponse(req, resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package ingress
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/config"
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+// bodySizeLimitMiddleware rejects requests whose body exceeds maxBytes
+// before they reach the origin.
+type bodySizeLimitMiddleware struct {
+	maxBytes int64
+}
+
+func newBodySizeLimitMiddleware(cfg *config.BodySizeLimitConfig) *bodySizeLimitMiddleware {
+	return &bodySizeLimitMiddleware{maxBytes: cfg.MaxBytes}
+}
+
+func (b *bodySizeLimitMiddleware) Name() string { return "bodySizeLimit" }
+
+func (b *bodySizeLimitMiddleware) AppliesTo(connectionType connection.Type) bool {
+	return connectionType == connection.TypeHTTP || connectionType == connection.TypeWebsocket
+}
+
+func (b *bodySizeLimitMiddleware) ProcessRequest(req *http.Request) (*http.Response, error) {
+	if req.ContentLength > b.maxBytes {
+		return entityTooLargeResponse(), nil
+	}
+	if req.Body != nil {
+		req.Body = &limitedBody{ReadCloser: req.Body, remaining: b.maxBytes}
+	}
+	return nil, nil
+}
+
+func (b *bodySizeLimitMiddleware) ProcessResponse(req *http.Request, resp *http.Response) error {
+	return nil
+}
+
+func entityTooLargeResponse() *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Body:       http.NoBody,
+		Header:     make(http.Header),
+	}
+}
+
+// limitedBody errors out once more than `remaining` bytes have been read,
+// rather than silently truncating the body like io.LimitReader would.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// The limit is used up; only fail if the body actually has more.
+		var probe [1]byte
+		n, err := l.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, fmt.Errorf("request body exceeds configured size limit")
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,88 @@
+package ingress
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/config"
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+// responseCompressionMiddleware negotiates Content-Encoding with the
+// client when the origin's response isn't already encoded, compressing
+// the body on the fly instead of buffering it.
+type responseCompressionMiddleware struct {
+	disabled bool
+}
+
+func newResponseCompressionMiddleware(cfg *config.ResponseCompressionConfig) *responseCompressionMiddleware {
+	return &responseCompressionMiddleware{disabled: cfg.Disable}
+}
+
+func (c *responseCompressionMiddleware) Name() string { return "responseCompression" }
+
+func (c *responseCompressionMiddleware) AppliesTo(connectionType connection.Type) bool {
+	return connectionType == connection.TypeHTTP
+}
+
+func (c *responseCompressionMiddleware) ProcessRequest(req *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func (c *responseCompressionMiddleware) ProcessResponse(req *http.Request, resp *http.Response) error {
+	if c.disabled || resp.Body == nil || resp.Body == http.NoBody || resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	encoding := negotiateEncoding(req.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return nil
+	}
+
+	resp.Body = compressBody(resp.Body, encoding)
+	resp.Header.Set("Content-Encoding", encoding)
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	return nil
+}
+
+// negotiateEncoding picks the best encoding cloudflared can produce for a
+// client's Accept-Encoding header, preferring br over gzip.
+func negotiateEncoding(acceptEncoding string) string {
+	for _, encoding := range []string{"br", "gzip"} {
+		if strings.Contains(acceptEncoding, encoding) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressBody streams body through the named encoder via an io.Pipe, so
+// the response is never buffered in full.
+func compressBody(body io.ReadCloser, encoding string) io.ReadCloser {
+	pr, pw := io.Pipe()
+
+	var enc io.WriteCloser
+	switch encoding {
+	case "br":
+		enc = brotli.NewWriter(pw)
+	default:
+		enc = gzip.NewWriter(pw)
+	}
+
+	go func() {
+		_, err := io.Copy(enc, body)
+		encErr := enc.Close()
+		body.Close()
+		if err == nil {
+			err = encErr
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return pr
+}
@@ -0,0 +1,34 @@
+package ingress
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/config"
+)
+
+func TestResponseCompressionMiddlewareSkipsBodilessResponses(t *testing.T) {
+	mw := newResponseCompressionMiddleware(&config.ResponseCompressionConfig{})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+
+	t.Run("nil body", func(t *testing.T) {
+		resp := &http.Response{Body: nil, Header: make(http.Header)}
+		require.NoError(t, mw.ProcessResponse(req, resp))
+		require.Empty(t, resp.Header.Get("Content-Encoding"))
+	})
+
+	t.Run("http.NoBody", func(t *testing.T) {
+		// http.NoBody is the sentinel net/http itself uses for bodiless
+		// responses (204s, http_status: rule responses); it's non-nil, so
+		// it must be checked for explicitly alongside nil.
+		resp := &http.Response{Body: http.NoBody, Header: make(http.Header)}
+		require.NoError(t, mw.ProcessResponse(req, resp))
+		require.Empty(t, resp.Header.Get("Content-Encoding"))
+		require.Equal(t, http.NoBody, resp.Body)
+	})
+}
@@ -0,0 +1,32 @@
+package ingress
+
+import (
+	"fmt"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/config"
+)
+
+// resolveMiddlewares builds the OriginMiddleware chain for a rule from its
+// config entries, preserving declaration order.
+func resolveMiddlewares(cfgs []config.MiddlewareConfig) ([]OriginMiddleware, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+
+	middlewares := make([]OriginMiddleware, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		switch {
+		case cfg.HeaderRewrite != nil:
+			middlewares = append(middlewares, newHeaderRewriteMiddleware(cfg.HeaderRewrite))
+		case cfg.JWTValidation != nil:
+			middlewares = append(middlewares, newJWTValidationMiddleware(cfg.JWTValidation))
+		case cfg.BodySizeLimit != nil:
+			middlewares = append(middlewares, newBodySizeLimitMiddleware(cfg.BodySizeLimit))
+		case cfg.ResponseCompression != nil:
+			middlewares = append(middlewares, newResponseCompressionMiddleware(cfg.ResponseCompression))
+		default:
+			return nil, fmt.Errorf("middleware %d doesn't configure any known middleware type", i)
+		}
+	}
+	return middlewares, nil
+}
@@ -0,0 +1,39 @@
+package ingress
+
+import (
+	"net/http"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/config"
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+// headerRewriteMiddleware injects or strips request headers before the
+// origin sees them.
+type headerRewriteMiddleware struct {
+	set    map[string]string
+	remove []string
+}
+
+func newHeaderRewriteMiddleware(cfg *config.HeaderRewriteConfig) *headerRewriteMiddleware {
+	return &headerRewriteMiddleware{set: cfg.Set, remove: cfg.Remove}
+}
+
+func (h *headerRewriteMiddleware) Name() string { return "headerRewrite" }
+
+func (h *headerRewriteMiddleware) AppliesTo(connectionType connection.Type) bool {
+	return connectionType == connection.TypeHTTP || connectionType == connection.TypeWebsocket
+}
+
+func (h *headerRewriteMiddleware) ProcessRequest(req *http.Request) (*http.Response, error) {
+	for name, value := range h.set {
+		req.Header.Set(name, value)
+	}
+	for _, name := range h.remove {
+		req.Header.Del(name)
+	}
+	return nil, nil
+}
+
+func (h *headerRewriteMiddleware) ProcessResponse(req *http.Request, resp *http.Response) error {
+	return nil
+}
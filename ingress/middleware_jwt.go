@@ -0,0 +1,221 @@
+package ingress
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/config"
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+// jwksCacheTTL bounds how long a fetched JWKS document is trusted before
+// it's re-fetched, so a key rotation on the identity provider is picked
+// up without restarting cloudflared.
+const jwksCacheTTL = 10 * time.Minute
+
+// jwtValidationMiddleware rejects requests that don't carry a bearer JWT
+// signed by a key published at jwksURL.
+type jwtValidationMiddleware struct {
+	jwksURL  string
+	issuer   string
+	audience []string
+
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+func newJWTValidationMiddleware(cfg *config.JWTValidationConfig) *jwtValidationMiddleware {
+	return &jwtValidationMiddleware{
+		jwksURL:    cfg.JWKSURL,
+		issuer:     cfg.Issuer,
+		audience:   cfg.Audience,
+		httpClient: http.DefaultClient,
+	}
+}
+
+func (j *jwtValidationMiddleware) Name() string { return "jwtValidation" }
+
+func (j *jwtValidationMiddleware) AppliesTo(connectionType connection.Type) bool {
+	return connectionType == connection.TypeHTTP || connectionType == connection.TypeWebsocket
+}
+
+func (j *jwtValidationMiddleware) ProcessRequest(req *http.Request) (*http.Response, error) {
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return unauthorizedResponse("missing bearer token"), nil
+	}
+	if err := j.validate(token); err != nil {
+		return unauthorizedResponse(err.Error()), nil
+	}
+	return nil, nil
+}
+
+func (j *jwtValidationMiddleware) ProcessResponse(req *http.Request, resp *http.Response) error {
+	return nil
+}
+
+func unauthorizedResponse(reason string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusUnauthorized,
+		Body:       io.NopCloser(strings.NewReader(reason)),
+		Header:     make(http.Header),
+	}
+}
+
+func (j *jwtValidationMiddleware) validate(token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed JWT")
+	}
+
+	headerJSON, err := decodeSegment(parts[0])
+	if err != nil {
+		return fmt.Errorf("invalid JWT header: %w", err)
+	}
+	var header struct {
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return fmt.Errorf("invalid JWT header: %w", err)
+	}
+
+	key, err := j.key(header.Kid)
+	if err != nil {
+		return err
+	}
+
+	sig, err := decodeSegment(parts[2])
+	if err != nil {
+		return fmt.Errorf("invalid JWT signature encoding: %w", err)
+	}
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], sig); err != nil {
+		return fmt.Errorf("invalid JWT signature: %w", err)
+	}
+
+	payloadJSON, err := decodeSegment(parts[1])
+	if err != nil {
+		return fmt.Errorf("invalid JWT payload: %w", err)
+	}
+	var claims struct {
+		Issuer   string   `json:"iss"`
+		Audience audience `json:"aud"`
+		Expiry   int64    `json:"exp"`
+	}
+	if err := json.Unmarshal(payloadJSON, &claims); err != nil {
+		return fmt.Errorf("invalid JWT payload: %w", err)
+	}
+	if j.issuer != "" && claims.Issuer != j.issuer {
+		return fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if len(j.audience) > 0 && !containsAny(claims.Audience, j.audience) {
+		return fmt.Errorf("token audience %v does not include any of %v", []string(claims.Audience), j.audience)
+	}
+	if claims.Expiry != 0 && time.Now().Unix() > claims.Expiry {
+		return fmt.Errorf("token expired")
+	}
+	return nil
+}
+
+// audience decodes a JWT "aud" claim, which per RFC 7519 §4.1.3 is either
+// a single string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+	*a = audience(multi)
+	return nil
+}
+
+// containsAny reports whether got shares at least one entry with want.
+func containsAny(got audience, want []string) bool {
+	for _, g := range got {
+		for _, w := range want {
+			if g == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(segment)
+}
+
+func (j *jwtValidationMiddleware) key(kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.keys == nil || time.Since(j.fetchedAt) > jwksCacheTTL {
+		if err := j.refreshLocked(); err != nil {
+			return nil, err
+		}
+	}
+	key, ok := j.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("no JWKS key found for kid %q", kid)
+	}
+	return key, nil
+}
+
+// refreshLocked fetches and caches the JWKS document. Callers must hold j.mu.
+func (j *jwtValidationMiddleware) refreshLocked() error {
+	resp, err := j.httpClient.Get(j.jwksURL)
+	if err != nil {
+		return fmt.Errorf("unable to fetch JWKS from %s: %w", j.jwksURL, err)
+	}
+	defer resp.Body.Close()
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return fmt.Errorf("unable to decode JWKS from %s: %w", j.jwksURL, err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		n, err := decodeSegment(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := decodeSegment(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,87 @@
+package ingress
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/config"
+)
+
+// startJWKSServer serves a JWKS document exposing key's public half under
+// kid, matching the format jwtValidationMiddleware.refreshLocked expects.
+func startJWKSServer(t *testing.T, key *rsa.PrivateKey, kid string) *httptest.Server {
+	t.Helper()
+	n := base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes())
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes())
+	body, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{"kid": kid, "n": n, "e": e}},
+	})
+	require.NoError(t, err)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(body)
+	}))
+}
+
+// mustSignJWT builds a JWT signed with key, in the RS256/PKCS1v15+SHA256
+// form jwtValidationMiddleware.validate verifies. A nil aud omits the
+// claim entirely.
+func mustSignJWT(t *testing.T, key *rsa.PrivateKey, kid, issuer string, aud interface{}, expiry int64) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	require.NoError(t, err)
+
+	payload := map[string]interface{}{"iss": issuer, "exp": expiry}
+	if aud != nil {
+		payload["aud"] = aud
+	}
+	payloadJSON, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payloadJSON)
+	hashed := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	require.NoError(t, err)
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTValidationAudience(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	const kid = "test-key"
+
+	jwks := startJWKSServer(t, key, kid)
+	defer jwks.Close()
+
+	mw := newJWTValidationMiddleware(&config.JWTValidationConfig{
+		JWKSURL:  jwks.URL,
+		Audience: []string{"api.example.com"},
+	})
+
+	t.Run("token with matching audience is accepted", func(t *testing.T) {
+		token := mustSignJWT(t, key, kid, "", "api.example.com", 0)
+		require.NoError(t, mw.validate(token))
+	})
+
+	t.Run("token with a different audience is rejected", func(t *testing.T) {
+		token := mustSignJWT(t, key, kid, "", "other.example.com", 0)
+		require.Error(t, mw.validate(token))
+	})
+
+	t.Run("token with no audience claim is rejected when audience is configured", func(t *testing.T) {
+		token := mustSignJWT(t, key, kid, "", nil, 0)
+		require.Error(t, mw.validate(token))
+	})
+}
@@ -0,0 +1,112 @@
+package ingress
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/hello"
+)
+
+// OriginService represents an origin that HTTP/websocket requests can be
+// proxied to. Implementations may need to be started (e.g. to spin up a
+// local server) before they can serve traffic.
+type OriginService interface {
+	http.RoundTripper
+
+	// Start performs any setup needed before the service can accept
+	// traffic, such as binding a listener. It must be safe to call
+	// Start on a service that needs no setup.
+	Start(wg *sync.WaitGroup, log *zerolog.Logger, shutdownC <-chan struct{}, errC chan error) error
+}
+
+// MockOriginHTTPService is a test double that proxies through an arbitrary
+// http.RoundTripper, used to simulate origin errors in proxy tests.
+type MockOriginHTTPService struct {
+	Transport http.RoundTripper
+}
+
+func (m MockOriginHTTPService) RoundTrip(req *http.Request) (*http.Response, error) {
+	return m.Transport.RoundTrip(req)
+}
+
+func (m MockOriginHTTPService) Start(wg *sync.WaitGroup, log *zerolog.Logger, shutdownC <-chan struct{}, errC chan error) error {
+	return nil
+}
+
+// httpService proxies to a single HTTP(S) origin reachable at url. When
+// helloworld is set, url starts empty and Start spins up the built-in
+// hello-world test origin on an ephemeral local listener, setting url to
+// its address.
+type httpService struct {
+	transport  http.RoundTripper
+	url        string
+	helloworld bool
+}
+
+func (h *httpService) RoundTrip(req *http.Request) (*http.Response, error) {
+	origin, err := url.Parse(h.url)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.Scheme = origin.Scheme
+	req.URL.Host = origin.Host
+	return h.transport.RoundTrip(req)
+}
+
+// DialContext dials the origin's host:port directly, bypassing
+// http.RoundTripper. It's used for websocket upgrades, which need a raw
+// duplex connection rather than a single request/response exchange.
+func (h *httpService) DialContext() (net.Conn, error) {
+	origin, err := url.Parse(h.url)
+	if err != nil {
+		return nil, err
+	}
+	return net.Dial("tcp", origin.Host)
+}
+
+func (h *httpService) Start(wg *sync.WaitGroup, log *zerolog.Logger, shutdownC <-chan struct{}, errC chan error) error {
+	if !h.helloworld {
+		return nil
+	}
+	srv := httptest.NewServer(hello.CreateMockOriginServer())
+	h.url = srv.URL
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-shutdownC
+		srv.Close()
+	}()
+	return nil
+}
+
+// tcpOverWSService proxies a raw TCP stream. DialContext is called with
+// the address the caller resolved from the request: the
+// h2mux.CFJumpDestinationHeader value when present, otherwise the request
+// Host. bastion records that the service was built for bastion mode;
+// dialing does not depend on it.
+type tcpOverWSService struct {
+	bastion bool
+}
+
+func (t *tcpOverWSService) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errNotHTTPService
+}
+
+func (t *tcpOverWSService) Start(wg *sync.WaitGroup, log *zerolog.Logger, shutdownC <-chan struct{}, errC chan error) error {
+	return nil
+}
+
+func (t *tcpOverWSService) DialContext(addr string) (net.Conn, error) {
+	return net.Dial("tcp", addr)
+}
+
+var errNotHTTPService = httpRoundTripError("service does not support HTTP requests")
+
+type httpRoundTripError string
+
+func (e httpRoundTripError) Error() string { return string(e) }
@@ -0,0 +1,96 @@
+package ingress
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/config"
+)
+
+const (
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultBreakerCooldown = 30 * time.Second
+)
+
+// idempotentMethods are the HTTP methods safe to retry automatically, per
+// RFC 7231 §4.2.2: repeating them can't cause an additional side effect
+// beyond the first attempt.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	http.MethodTrace:   true,
+}
+
+// IsIdempotentMethod reports whether method is safe for RetryPolicy to
+// retry without risking a duplicated side effect on the origin.
+func IsIdempotentMethod(method string) bool {
+	return idempotentMethods[method]
+}
+
+// RetryPolicy bundles per-rule retry count/backoff with the circuit
+// breaker guarding the origin the retries would hit.
+type RetryPolicy struct {
+	maxRetries  uint
+	backoffBase time.Duration
+	breaker     *circuitBreaker
+}
+
+// NewRetryPolicy builds a RetryPolicy from a rule's retry config. It
+// returns nil when cfg is nil, meaning the rule neither retries nor
+// trips a breaker.
+func NewRetryPolicy(cfg *config.RetryConfig) *RetryPolicy {
+	if cfg == nil {
+		return nil
+	}
+
+	backoffBase := defaultRetryBackoff
+	if cfg.RetryBackoff != nil {
+		backoffBase = *cfg.RetryBackoff
+	}
+	cooldown := defaultBreakerCooldown
+	if cfg.BreakerCooldown != nil {
+		cooldown = *cfg.BreakerCooldown
+	}
+
+	return &RetryPolicy{
+		maxRetries:  cfg.MaxRetries,
+		backoffBase: backoffBase,
+		breaker:     newCircuitBreaker(cfg.BreakerFailureThreshold, cooldown),
+	}
+}
+
+// MaxRetries is how many additional attempts an idempotent request gets
+// after its first failed attempt.
+func (p *RetryPolicy) MaxRetries() uint {
+	return p.maxRetries
+}
+
+// Allow reports whether the breaker will currently let a request reach
+// the origin.
+func (p *RetryPolicy) Allow() bool {
+	return p.breaker.allow()
+}
+
+// RecordSuccess closes the breaker after an origin request succeeds.
+func (p *RetryPolicy) RecordSuccess() {
+	p.breaker.recordSuccess()
+}
+
+// RecordFailure counts an origin request failure towards tripping the
+// breaker open.
+func (p *RetryPolicy) RecordFailure() {
+	p.breaker.recordFailure()
+}
+
+// Backoff returns the delay before retry attempt (1-indexed), doubling
+// each attempt and applying up to ±25% jitter so concurrent retrying
+// requests don't all hit the origin in lockstep.
+func (p *RetryPolicy) Backoff(attempt uint) time.Duration {
+	base := p.backoffBase << (attempt - 1)
+	jitter := time.Duration(rand.Int63n(int64(base)/2+1)) - base/4
+	return base + jitter
+}
@@ -0,0 +1,37 @@
+package ingress
+
+import (
+	"regexp"
+)
+
+// Rule routes a request matching Hostname/Path to Service.
+type Rule struct {
+	Hostname string
+	// Path is nil when the rule matches every path under Hostname.
+	Path    *regexp.Regexp
+	Service OriginService
+
+	// Middlewares run, in order, around every request this rule proxies.
+	Middlewares []OriginMiddleware
+
+	// RetryPolicy is nil when the rule has no retry/circuit-breaker
+	// config, in which case origin requests are attempted exactly once.
+	RetryPolicy *RetryPolicy
+}
+
+func (r *Rule) matches(hostname, path string) bool {
+	if !matchHost(r.Hostname, hostname) {
+		return false
+	}
+	if r.Path == nil {
+		return true
+	}
+	return r.Path.MatchString(path)
+}
+
+func matchHost(pattern, hostname string) bool {
+	if pattern == "*" || pattern == "" {
+		return true
+	}
+	return pattern == hostname
+}
@@ -0,0 +1,47 @@
+package ingress
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+const udpServicePrefix = "udp://"
+
+// udpService proxies UDP datagrams to a single fixed origin address. The
+// connection layer is responsible for carrying datagrams to/from the
+// edge (as HTTP/3 DATAGRAM frames over quic, or length-prefixed frames
+// over an h2mux stream); this service only owns the origin-side socket.
+type udpService struct {
+	originAddr string
+}
+
+func newUDPService(rawURL string) (*udpService, error) {
+	originAddr := strings.TrimPrefix(rawURL, udpServicePrefix)
+	if _, _, err := net.SplitHostPort(originAddr); err != nil {
+		return nil, err
+	}
+	return &udpService{originAddr: originAddr}, nil
+}
+
+func (u *udpService) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errNotHTTPService
+}
+
+func (u *udpService) Start(wg *sync.WaitGroup, log *zerolog.Logger, shutdownC <-chan struct{}, errC chan error) error {
+	return nil
+}
+
+// DialUDP opens a new UDP flow to the origin. Each call is a distinct
+// flow: callers proxying multiple concurrent clients dial once per
+// client, matching UDP's connectionless semantics.
+func (u *udpService) DialUDP() (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", u.originAddr)
+	if err != nil {
+		return nil, err
+	}
+	return net.DialUDP("udp", nil, addr)
+}
@@ -0,0 +1,14 @@
+package logger
+
+import (
+	"os"
+
+	"github.com/rs/zerolog"
+)
+
+// Create returns a timestamped console logger writing to stderr. flags is
+// not read: every call yields the same configuration.
+func Create(flags interface{}) *zerolog.Logger {
+	log := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
+	return &log
+}
@@ -0,0 +1,461 @@
+package origin
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/h2mux"
+	"github.com/cloudflare/cloudflared/ingress"
+	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+	"github.com/cloudflare/cloudflared/websocket"
+)
+
+// OriginProxy is the connection.OriginProxy implementation that routes a
+// request through the tunnel's ingress rules to the matching origin
+// service.
+type OriginProxy struct {
+	ingressRules ingress.Ingress
+	tags         []tunnelpogs.Tag
+	log          *zerolog.Logger
+}
+
+// NewOriginProxy creates an OriginProxy that serves traffic through
+// ingressRules. tags are stored on the proxy; they are not added to the
+// requests it sends to origins.
+func NewOriginProxy(ingressRules ingress.Ingress, tags []tunnelpogs.Tag, log *zerolog.Logger) *OriginProxy {
+	return &OriginProxy{
+		ingressRules: ingressRules,
+		tags:         tags,
+		log:          log,
+	}
+}
+
+func (o *OriginProxy) Proxy(w connection.ResponseWriter, req *http.Request, connectionType connection.Type) error {
+	rule, err := o.ingressRules.FindMatchingRule(req.Host, req.URL.Path)
+	if err != nil {
+		w.WriteErrorResponse()
+		return err
+	}
+
+	switch connectionType {
+	case connection.TypeHTTP:
+		return o.proxyHTTP(w, req, rule, connectionType)
+	case connection.TypeWebsocket:
+		return o.proxyWebsocket(w, req, rule)
+	case connection.TypeTCP:
+		return o.proxyTCP(w, req, rule)
+	case connection.TypeUDP:
+		return o.proxyUDP(w, req, rule)
+	default:
+		w.WriteErrorResponse()
+		return fmt.Errorf("unsupported connection type %s", connectionType)
+	}
+}
+
+func (o *OriginProxy) proxyHTTP(w connection.ResponseWriter, req *http.Request, rule *ingress.Rule, connType connection.Type) error {
+	if shortCircuit, err := ingress.RunRequestMiddlewares(rule.Middlewares, connType, req); err != nil || shortCircuit != nil {
+		if err != nil {
+			w.WriteErrorResponse()
+			return err
+		}
+		return o.writeHTTPResponse(w, req, shortCircuit, rule, connType)
+	}
+
+	resp, err := o.roundTrip(rule, req)
+	if err != nil {
+		if err == errCircuitBreakerOpen {
+			return writeServiceUnavailable(w, err)
+		}
+		if isGRPCService(rule.Service) {
+			return writeGRPCError(w, err)
+		}
+		w.WriteErrorResponse()
+		return err
+	}
+	defer resp.Body.Close()
+
+	return o.writeHTTPResponse(w, req, resp, rule, connType)
+}
+
+// proxyWebsocket proxies a websocket connection. Unlike proxyHTTP, this
+// can't be satisfied by a single http.RoundTripper exchange: a websocket
+// connection is a duplex byte stream that outlives the upgrade response,
+// so the origin is dialed directly and the upgrade handshake is replayed
+// onto that raw connection, similar to proxyTCP.
+func (o *OriginProxy) proxyWebsocket(w connection.ResponseWriter, req *http.Request, rule *ingress.Rule) error {
+	if shortCircuit, err := ingress.RunRequestMiddlewares(rule.Middlewares, connection.TypeWebsocket, req); err != nil {
+		w.WriteErrorResponse()
+		return err
+	} else if shortCircuit != nil {
+		if err := w.WriteRespHeaders(shortCircuit.StatusCode, shortCircuit.Header); err != nil {
+			return fmt.Errorf("unable to write response headers: %w", err)
+		}
+		return nil
+	}
+
+	// A rule whose service only dials raw TCP (teamnet/bastion mode) has
+	// no HTTP-level origin to upgrade with; the bytes it carries are
+	// already the websocket stream, so tunnel them exactly like TCP.
+	if tcpService, ok := rule.Service.(tcpDialer); ok {
+		addr := req.Host
+		if jumpDest := req.Header.Get(h2mux.CFJumpDestinationHeader); jumpDest != "" {
+			addr = jumpDest
+		}
+		conn, err := tcpService.DialContext(addr)
+		if err != nil {
+			w.WriteErrorResponse()
+			return fmt.Errorf("unable to dial %s: %w", addr, err)
+		}
+		defer conn.Close()
+
+		if err := w.WriteRespHeaders(http.StatusSwitchingProtocols, http.Header{}); err != nil {
+			return fmt.Errorf("unable to write response headers: %w", err)
+		}
+		return shuttleBytes(w, websocket.NewConn(conn, nil))
+	}
+
+	wsService, ok := rule.Service.(websocketDialer)
+	if !ok {
+		w.WriteErrorResponse()
+		return fmt.Errorf("rule for %s does not support websocket connections", rule.Hostname)
+	}
+
+	conn, err := wsService.DialContext()
+	if err != nil {
+		w.WriteErrorResponse()
+		return fmt.Errorf("unable to dial websocket origin: %w", err)
+	}
+	defer conn.Close()
+
+	ensureWebsocketUpgradeHeaders(req)
+
+	if err := req.Write(conn); err != nil {
+		w.WriteErrorResponse()
+		return fmt.Errorf("unable to write websocket upgrade request: %w", err)
+	}
+
+	originConn := bufio.NewReader(conn)
+	originResp, err := http.ReadResponse(originConn, req)
+	if err != nil {
+		w.WriteErrorResponse()
+		return fmt.Errorf("unable to read websocket upgrade response: %w", err)
+	}
+	defer originResp.Body.Close()
+	if originResp.StatusCode != http.StatusSwitchingProtocols {
+		w.WriteErrorResponse()
+		return fmt.Errorf("origin refused websocket upgrade with status %d", originResp.StatusCode)
+	}
+
+	if err := w.WriteRespHeaders(http.StatusSwitchingProtocols, http.Header{}); err != nil {
+		return fmt.Errorf("unable to write response headers: %w", err)
+	}
+
+	// Reads must go through originConn rather than conn directly: the
+	// bufio.Reader may have already buffered origin bytes sent right
+	// after the upgrade response, and reading from conn would skip them.
+	return shuttleBytes(w, bufferedConn{reader: originConn, conn: conn})
+}
+
+// websocketDialer is implemented by origin services that are reached over
+// HTTP and therefore need a real upgrade handshake replayed on the raw
+// connection before the websocket stream can be tunneled.
+type websocketDialer interface {
+	DialContext() (net.Conn, error)
+}
+
+// ensureWebsocketUpgradeHeaders adds the headers a websocket upgrade
+// request requires if they aren't already set. The edge only tells the
+// proxy a connection is a websocket via connectionType, so the request
+// itself may not carry them.
+func ensureWebsocketUpgradeHeaders(req *http.Request) {
+	if req.Header.Get("Sec-WebSocket-Key") != "" {
+		return
+	}
+	key := make([]byte, 16)
+	_, _ = rand.Read(key)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+}
+
+// bufferedConn pairs a buffered reader sitting in front of conn with
+// conn's own Write/Close, so bytes conn buffered while reading an HTTP
+// response aren't lost once the connection is handed off as a raw stream.
+type bufferedConn struct {
+	reader io.Reader
+	conn   net.Conn
+}
+
+func (c bufferedConn) Read(p []byte) (int, error)  { return c.reader.Read(p) }
+func (c bufferedConn) Write(p []byte) (int, error) { return c.conn.Write(p) }
+func (c bufferedConn) Close() error                { return c.conn.Close() }
+
+// errCircuitBreakerOpen is returned by roundTrip when a rule's breaker is
+// fast-failing requests rather than letting them reach a struggling origin.
+var errCircuitBreakerOpen = fmt.Errorf("circuit breaker is open for this origin")
+
+// roundTrip calls the origin, applying rule's retry policy if it has one:
+// idempotent requests are retried with backoff on failure, and every
+// attempt (including the first) is gated by the rule's circuit breaker.
+func (o *OriginProxy) roundTrip(rule *ingress.Rule, req *http.Request) (*http.Response, error) {
+	policy := rule.RetryPolicy
+	if policy == nil {
+		return rule.Service.RoundTrip(req)
+	}
+
+	if !policy.Allow() {
+		return nil, errCircuitBreakerOpen
+	}
+
+	resp, err := rule.Service.RoundTrip(req)
+	if err == nil {
+		policy.RecordSuccess()
+		return resp, nil
+	}
+	policy.RecordFailure()
+
+	if !ingress.IsIdempotentMethod(req.Method) {
+		return nil, err
+	}
+
+	for attempt := uint(1); attempt <= policy.MaxRetries(); attempt++ {
+		if !policy.Allow() {
+			return nil, errCircuitBreakerOpen
+		}
+		time.Sleep(policy.Backoff(attempt))
+
+		if req.Body != nil && req.GetBody != nil {
+			body, berr := req.GetBody()
+			if berr != nil {
+				return nil, berr
+			}
+			req.Body = body
+		}
+
+		resp, err = rule.Service.RoundTrip(req)
+		if err == nil {
+			policy.RecordSuccess()
+			return resp, nil
+		}
+		policy.RecordFailure()
+	}
+	return nil, err
+}
+
+func writeServiceUnavailable(w connection.ResponseWriter, err error) error {
+	if werr := w.WriteRespHeaders(http.StatusServiceUnavailable, http.Header{}); werr != nil {
+		return fmt.Errorf("unable to write response headers: %w", werr)
+	}
+	return err
+}
+
+func (o *OriginProxy) writeHTTPResponse(w connection.ResponseWriter, req *http.Request, resp *http.Response, rule *ingress.Rule, connType connection.Type) error {
+	if err := ingress.RunResponseMiddlewares(rule.Middlewares, connType, req, resp); err != nil {
+		w.WriteErrorResponse()
+		return fmt.Errorf("origin middleware rejected response: %w", err)
+	}
+
+	if err := w.WriteRespHeaders(resp.StatusCode, resp.Header); err != nil {
+		return fmt.Errorf("unable to write response headers: %w", err)
+	}
+
+	if resp.Body == http.NoBody {
+		return nil
+	}
+	_, copyErr := io.Copy(w, resp.Body)
+
+	// gRPC reports the final RPC outcome as HTTP/2 trailers
+	// (grpc-status, grpc-message), which only become available once the
+	// body has been fully read.
+	if tw, ok := w.(trailerWriter); ok && len(resp.Trailer) > 0 {
+		if err := tw.WriteTrailer(resp.Trailer); err != nil {
+			return fmt.Errorf("unable to write trailers: %w", err)
+		}
+	}
+	return copyErr
+}
+
+// trailerWriter is implemented by ResponseWriters for transports that
+// support HTTP/2 trailers (e.g. gRPC). It's checked with a type assertion
+// rather than added to connection.ResponseWriter because most connection
+// types never need it.
+type trailerWriter interface {
+	WriteTrailer(trailer http.Header) error
+}
+
+type grpcService interface {
+	IsGRPCService() bool
+}
+
+func isGRPCService(service ingress.OriginService) bool {
+	g, ok := service.(grpcService)
+	return ok && g.IsGRPCService()
+}
+
+// writeGRPCError reports an origin round-trip failure the way a gRPC
+// client expects: HTTP 200 with grpc-status/grpc-message conveying the
+// real outcome, since gRPC status codes are independent of the HTTP
+// status line.
+func writeGRPCError(w connection.ResponseWriter, origErr error) error {
+	header := http.Header{
+		"Grpc-Status":  []string{"14"}, // UNAVAILABLE
+		"Grpc-Message": []string{origErr.Error()},
+	}
+	if err := w.WriteRespHeaders(http.StatusOK, header); err != nil {
+		return fmt.Errorf("unable to write grpc error headers: %w", err)
+	}
+	return origErr
+}
+
+func (o *OriginProxy) proxyTCP(w connection.ResponseWriter, req *http.Request, rule *ingress.Rule) error {
+	// A raw TCP stream has no response to mutate, so only the request
+	// side of the chain runs here; a middleware can still short-circuit
+	// the connection (e.g. an IP allowlist) by returning a response.
+	if shortCircuit, err := ingress.RunRequestMiddlewares(rule.Middlewares, connection.TypeTCP, req); err != nil {
+		w.WriteErrorResponse()
+		return err
+	} else if shortCircuit != nil {
+		if err := w.WriteRespHeaders(shortCircuit.StatusCode, shortCircuit.Header); err != nil {
+			return fmt.Errorf("unable to write response headers: %w", err)
+		}
+		return nil
+	}
+
+	tcpService, ok := rule.Service.(tcpDialer)
+	if !ok {
+		w.WriteErrorResponse()
+		return fmt.Errorf("rule for %s does not support TCP connections", rule.Hostname)
+	}
+
+	addr := req.Host
+	if jumpDest := req.Header.Get(h2mux.CFJumpDestinationHeader); jumpDest != "" {
+		addr = jumpDest
+	}
+
+	conn, err := tcpService.DialContext(addr)
+	if err != nil {
+		w.WriteErrorResponse()
+		return fmt.Errorf("unable to dial %s: %w", addr, err)
+	}
+	defer conn.Close()
+
+	if err := w.WriteRespHeaders(http.StatusSwitchingProtocols, http.Header{}); err != nil {
+		return fmt.Errorf("unable to write response headers: %w", err)
+	}
+
+	return shuttleBytes(w, websocket.NewConn(conn, nil))
+}
+
+type tcpDialer interface {
+	DialContext(addr string) (net.Conn, error)
+}
+
+// shuttleBytes copies bytes in both directions between w and conn until
+// either side closes or errors, used for connection types (TCP,
+// websocket) that are a raw duplex stream rather than a single
+// request/response.
+func shuttleBytes(w connection.ResponseWriter, conn io.ReadWriteCloser) error {
+	errC := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(w, conn)
+		errC <- err
+	}()
+	go func() {
+		_, err := io.Copy(conn, w)
+		errC <- err
+	}()
+	return <-errC
+}
+
+// maxDatagramPayloadSize keeps each read comfortably within a single QUIC
+// DATAGRAM frame on a path with a standard 1500-byte MTU.
+const maxDatagramPayloadSize = 1452
+
+// udpIdleTimeout bounds how long each direction of a UDP flow may wait for
+// a datagram: a read from the origin, or from the edge when the writer
+// supports deadlines, that waits longer than this ends the whole flow,
+// since UDP has no transport-level signal that a flow is done.
+const udpIdleTimeout = 65 * time.Second
+
+func (o *OriginProxy) proxyUDP(w connection.ResponseWriter, req *http.Request, rule *ingress.Rule) error {
+	dialer, ok := rule.Service.(udpDialer)
+	if !ok {
+		w.WriteErrorResponse()
+		return fmt.Errorf("rule for %s does not support UDP connections", rule.Hostname)
+	}
+
+	conn, err := dialer.DialUDP()
+	if err != nil {
+		w.WriteErrorResponse()
+		return fmt.Errorf("unable to dial udp origin: %w", err)
+	}
+	defer conn.Close()
+
+	if err := w.WriteRespHeaders(http.StatusOK, http.Header{}); err != nil {
+		return fmt.Errorf("unable to write response headers: %w", err)
+	}
+
+	metrics := connection.NewUDPFlowMetrics()
+	defer metrics.Close()
+
+	dw, hasDeadlines := w.(connection.DatagramResponseWriter)
+
+	errC := make(chan error, 2)
+	go func() { // origin -> edge
+		buf := make([]byte, maxDatagramPayloadSize)
+		for {
+			_ = conn.SetReadDeadline(time.Now().Add(udpIdleTimeout))
+			n, err := conn.Read(buf)
+			if n > 0 {
+				metrics.AddOriginToEdge(n)
+				if hasDeadlines {
+					_ = dw.SetWriteDeadline(time.Now().Add(udpIdleTimeout))
+				}
+				if _, werr := w.Write(buf[:n]); werr != nil {
+					errC <- werr
+					return
+				}
+			}
+			if err != nil {
+				errC <- err
+				return
+			}
+		}
+	}()
+	go func() { // edge -> origin
+		buf := make([]byte, maxDatagramPayloadSize)
+		for {
+			if hasDeadlines {
+				_ = dw.SetReadDeadline(time.Now().Add(udpIdleTimeout))
+			}
+			n, err := w.Read(buf)
+			if n > 0 {
+				metrics.AddEdgeToOrigin(n)
+				_ = conn.SetWriteDeadline(time.Now().Add(udpIdleTimeout))
+				if _, werr := conn.Write(buf[:n]); werr != nil {
+					errC <- werr
+					return
+				}
+			}
+			if err != nil {
+				errC <- err
+				return
+			}
+		}
+	}()
+	return <-errC
+}
+
+type udpDialer interface {
+	DialUDP() (*net.UDPConn, error)
+}
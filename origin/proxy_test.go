@@ -27,6 +27,8 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 var (
@@ -35,11 +37,12 @@ var (
 
 type mockHTTPRespWriter struct {
 	*httptest.ResponseRecorder
+	trailer http.Header
 }
 
 func newMockHTTPRespWriter() *mockHTTPRespWriter {
 	return &mockHTTPRespWriter{
-		httptest.NewRecorder(),
+		ResponseRecorder: httptest.NewRecorder(),
 	}
 }
 
@@ -51,6 +54,13 @@ func (w *mockHTTPRespWriter) WriteRespHeaders(status int, header http.Header) er
 	return nil
 }
 
+// WriteTrailer records the trailer it's given so tests can assert on it;
+// it's what makes mockHTTPRespWriter satisfy origin's trailerWriter.
+func (w *mockHTTPRespWriter) WriteTrailer(trailer http.Header) error {
+	w.trailer = trailer
+	return nil
+}
+
 func (w *mockHTTPRespWriter) WriteErrorResponse() {
 	w.WriteHeader(http.StatusBadGateway)
 	_, _ = w.Write([]byte("http response error"))
@@ -510,3 +520,453 @@ func testTCPStreamProxy(proxy connection.OriginProxy) func(t *testing.T) {
 		cancel()
 	}
 }
+
+// grpcOriginHandler fakes a gRPC server speaking raw HTTP/2: it ignores the
+// framing of the request body and just distinguishes a unary call
+// (/test.Greeter/SayHello) from a server-streaming one
+// (/test.Greeter/SayHelloStream) by path, trailing grpc-status on both.
+// chunkDelay is applied between streamed chunks so a test can exercise
+// what happens when the response isn't already fully buffered by the
+// time the client reads it.
+func grpcOriginHandler(streamChunks int, chunkDelay time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.Header().Set("Content-Type", "application/grpc")
+
+		switch r.URL.Path {
+		case "/test.Greeter/SayHelloStream":
+			flusher := w.(http.Flusher)
+			for i := 0; i < streamChunks; i++ {
+				if i > 0 && chunkDelay > 0 {
+					time.Sleep(chunkDelay)
+				}
+				_, _ = w.Write([]byte{byte(i)})
+				flusher.Flush()
+			}
+		default:
+			_, _ = w.Write([]byte("unary response"))
+		}
+
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set("Grpc-Message", "")
+	})
+}
+
+func TestProxyGRPC(t *testing.T) {
+	// grpc:// dials in plaintext and speaks HTTP/2 with prior knowledge
+	// (H2C), so the fake origin is a plain H2C listener rather than a
+	// TLS server. Chunks are spaced out so a Grpc-Timeout deadline that's
+	// canceled too early (e.g. as soon as headers arrive) would truncate
+	// the stream instead of merely being a theoretical race.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	h2cSrv := &http.Server{
+		Handler: h2c.NewHandler(grpcOriginHandler(3, 20*time.Millisecond), &http2.Server{}),
+	}
+	go func() { _ = h2cSrv.Serve(ln) }()
+	defer h2cSrv.Close()
+
+	unvalidatedIngress := []config.UnvalidatedIngressRule{
+		{
+			Hostname: "*",
+			Service:  "grpc://" + ln.Addr().String(),
+		},
+	}
+	ingressRule, err := ingress.ParseIngress(&config.Configuration{
+		TunnelID: t.Name(),
+		Ingress:  unvalidatedIngress,
+	})
+	require.NoError(t, err)
+
+	log := zerolog.Nop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errC := make(chan error)
+	require.NoError(t, ingressRule.StartOrigins(&wg, &log, ctx.Done(), errC))
+
+	proxy := NewOriginProxy(ingressRule, testTags, &log)
+
+	t.Run("unary", testProxyGRPCUnary(proxy))
+	t.Run("serverStreaming", testProxyGRPCServerStreaming(proxy))
+}
+
+func testProxyGRPCUnary(proxy connection.OriginProxy) func(t *testing.T) {
+	return func(t *testing.T) {
+		respWriter := newMockHTTPRespWriter()
+		req, err := http.NewRequest(http.MethodPost, "http://localhost/test.Greeter/SayHello", nil)
+		require.NoError(t, err)
+		req.Header.Set("Grpc-Timeout", "1S")
+
+		err = proxy.Proxy(respWriter, req, connection.TypeHTTP)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, respWriter.Code)
+		assert.Contains(t, respWriter.Body.String(), "unary response")
+		assert.Equal(t, "0", respWriter.trailer.Get("Grpc-Status"))
+	}
+}
+
+func testProxyGRPCServerStreaming(proxy connection.OriginProxy) func(t *testing.T) {
+	return func(t *testing.T) {
+		respWriter := newMockHTTPRespWriter()
+		req, err := http.NewRequest(http.MethodPost, "http://localhost/test.Greeter/SayHelloStream", nil)
+		require.NoError(t, err)
+		// A generous timeout that would never itself expire during the
+		// test: if the context behind it is canceled the instant headers
+		// arrive (rather than when the body is done being read), the
+		// later, delayed chunks get truncated with "context canceled".
+		req.Header.Set("Grpc-Timeout", "10S")
+
+		err = proxy.Proxy(respWriter, req, connection.TypeHTTP)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, respWriter.Code)
+		assert.Equal(t, 3, respWriter.Body.Len())
+		assert.Equal(t, "0", respWriter.trailer.Get("Grpc-Status"))
+	}
+}
+
+func TestUDPStream(t *testing.T) {
+	echoAddr, stopEcho := startUDPEchoServer(t)
+	defer stopEcho()
+
+	logger := logger.Create(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ingressConfig := &config.Configuration{
+		Ingress: []config.UnvalidatedIngressRule{
+			{
+				Hostname: "*",
+				Service:  "udp://" + echoAddr,
+			},
+		},
+	}
+	ingressRule, err := ingress.ParseIngress(ingressConfig)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errC := make(chan error)
+	require.NoError(t, ingressRule.StartOrigins(&wg, logger, ctx.Done(), errC))
+
+	proxy := NewOriginProxy(ingressRule, testTags, logger)
+
+	t.Run("testUDPStream", testUDPStreamProxy(proxy))
+	cancel()
+	wg.Wait()
+}
+
+// startUDPEchoServer starts a loopback UDP server that echoes back every
+// datagram it receives, and returns its address and a func to stop it.
+func startUDPEchoServer(t *testing.T) (string, func()) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, raddr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if _, err := conn.WriteToUDP(buf[:n], raddr); err != nil {
+				return
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String(), func() {
+		conn.Close()
+	}
+}
+
+type mockUDPRespWriter struct {
+	w io.Writer
+	r io.Reader
+
+	mu             sync.Mutex
+	code           int
+	readDeadlines  []time.Time
+	writeDeadlines []time.Time
+}
+
+func (m *mockUDPRespWriter) Read(p []byte) (int, error) {
+	return m.r.Read(p)
+}
+
+func (m *mockUDPRespWriter) Write(p []byte) (int, error) {
+	return m.w.Write(p)
+}
+
+func (m *mockUDPRespWriter) WriteErrorResponse() {}
+
+func (m *mockUDPRespWriter) WriteRespHeaders(status int, header http.Header) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.code = status
+	return nil
+}
+
+func (m *mockUDPRespWriter) SetReadDeadline(t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.readDeadlines = append(m.readDeadlines, t)
+	return nil
+}
+
+func (m *mockUDPRespWriter) SetWriteDeadline(t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.writeDeadlines = append(m.writeDeadlines, t)
+	return nil
+}
+
+func testUDPStreamProxy(proxy connection.OriginProxy) func(t *testing.T) {
+	return func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		readPipe, writePipe := io.Pipe()
+		clientReadPipe, clientWritePipe := io.Pipe()
+		respWriter := &mockUDPRespWriter{w: writePipe, r: clientReadPipe}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://dummy", nil)
+		require.NoError(t, err)
+
+		go func() {
+			_ = proxy.Proxy(respWriter, req, connection.TypeUDP)
+		}()
+
+		msg := []byte("hello from the edge")
+		_, err = clientWritePipe.Write(msg)
+		require.NoError(t, err)
+
+		echoed := make([]byte, len(msg))
+		_, err = io.ReadFull(readPipe, echoed)
+		require.NoError(t, err)
+		require.Equal(t, msg, echoed)
+
+		// The edge-facing read deadline should be refreshed before every
+		// read from the edge, bounding how long an idle flow is kept open.
+		respWriter.mu.Lock()
+		require.NotEmpty(t, respWriter.readDeadlines)
+		respWriter.mu.Unlock()
+
+		cancel()
+	}
+}
+
+func TestProxyMiddlewareChain(t *testing.T) {
+	const echoHeader = "X-Test-Header"
+
+	originSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Echo-"+echoHeader, r.Header.Get(echoHeader))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer originSrv.Close()
+
+	tests := []struct {
+		name           string
+		middlewares    []config.MiddlewareConfig
+		expectedStatus int
+		expectedEcho   string
+	}{
+		{
+			name: "header rewrite mutates the request before the origin sees it",
+			middlewares: []config.MiddlewareConfig{
+				{HeaderRewrite: &config.HeaderRewriteConfig{Set: map[string]string{echoHeader: "injected"}}},
+			},
+			expectedStatus: http.StatusOK,
+			expectedEcho:   "injected",
+		},
+		{
+			name: "jwt validation short-circuits a request with no bearer token",
+			middlewares: []config.MiddlewareConfig{
+				{JWTValidation: &config.JWTValidationConfig{JWKSURL: "http://127.0.0.1:0"}},
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			unvalidatedIngress := []config.UnvalidatedIngressRule{
+				{
+					Hostname: "*",
+					Service:  originSrv.URL,
+					OriginRequest: config.OriginRequestConfig{
+						Middlewares: test.middlewares,
+					},
+				},
+			}
+			ingressRule, err := ingress.ParseIngress(&config.Configuration{
+				TunnelID: t.Name(),
+				Ingress:  unvalidatedIngress,
+			})
+			require.NoError(t, err)
+
+			log := zerolog.Nop()
+			ctx, cancel := context.WithCancel(context.Background())
+			var wg sync.WaitGroup
+			errC := make(chan error)
+			require.NoError(t, ingressRule.StartOrigins(&wg, &log, ctx.Done(), errC))
+
+			proxy := NewOriginProxy(ingressRule, testTags, &log)
+
+			respWriter := newMockHTTPRespWriter()
+			req, err := http.NewRequest(http.MethodGet, "http://localhost", nil)
+			require.NoError(t, err)
+			req.Header.Set(echoHeader, "original")
+
+			err = proxy.Proxy(respWriter, req, connection.TypeHTTP)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedStatus, respWriter.Code)
+			if test.expectedEcho != "" {
+				assert.Equal(t, test.expectedEcho, respWriter.Header().Get("Echo-"+echoHeader))
+			}
+
+			cancel()
+			wg.Wait()
+		})
+	}
+}
+
+// countingTransport always fails, recording how many times RoundTrip was
+// called so tests can assert on retry counts.
+type countingTransport struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *countingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	return nil, fmt.Errorf("origin unavailable")
+}
+
+func (c *countingTransport) Calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+// bodyRecordingTransport fails the first call and succeeds on every call
+// after, recording the request body bytes it observed on each call so
+// tests can assert a retried request replays its original body.
+type bodyRecordingTransport struct {
+	mu     sync.Mutex
+	bodies [][]byte
+}
+
+func (b *bodyRecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+
+	b.mu.Lock()
+	b.bodies = append(b.bodies, body)
+	attempt := len(b.bodies)
+	b.mu.Unlock()
+
+	if attempt == 1 {
+		return nil, fmt.Errorf("origin unavailable")
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Header: make(http.Header)}, nil
+}
+
+func TestProxyRetryAndCircuitBreaker(t *testing.T) {
+	newProxy := func(transport http.RoundTripper, retries *config.RetryConfig) connection.OriginProxy {
+		log := zerolog.Nop()
+		rule := ingress.Rule{
+			Hostname:    "*",
+			Service:     ingress.MockOriginHTTPService{Transport: transport},
+			RetryPolicy: ingress.NewRetryPolicy(retries),
+		}
+		return NewOriginProxy(ingress.Ingress{Rules: []ingress.Rule{rule}}, testTags, &log)
+	}
+
+	t.Run("idempotent requests are retried up to the configured max", func(t *testing.T) {
+		transport := &countingTransport{}
+		proxy := newProxy(transport, &config.RetryConfig{
+			MaxRetries:              2,
+			BreakerFailureThreshold: 10, // high enough that the breaker doesn't trip mid-test
+		})
+
+		respWriter := newMockHTTPRespWriter()
+		req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1", nil)
+		require.NoError(t, err)
+
+		err = proxy.Proxy(respWriter, req, connection.TypeHTTP)
+		assert.Error(t, err)
+		assert.Equal(t, 3, transport.Calls()) // first attempt + 2 retries
+	})
+
+	t.Run("non-idempotent requests are never retried", func(t *testing.T) {
+		transport := &countingTransport{}
+		proxy := newProxy(transport, &config.RetryConfig{
+			MaxRetries:              5,
+			BreakerFailureThreshold: 10,
+		})
+
+		respWriter := newMockHTTPRespWriter()
+		req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1", nil)
+		require.NoError(t, err)
+
+		err = proxy.Proxy(respWriter, req, connection.TypeHTTP)
+		assert.Error(t, err)
+		assert.Equal(t, 1, transport.Calls())
+	})
+
+	t.Run("idempotent requests with a body replay the same body on retry", func(t *testing.T) {
+		transport := &bodyRecordingTransport{}
+		proxy := newProxy(transport, &config.RetryConfig{
+			MaxRetries:              1,
+			BreakerFailureThreshold: 10,
+		})
+
+		respWriter := newMockHTTPRespWriter()
+		body := []byte("payload")
+		req, err := http.NewRequest(http.MethodPut, "http://127.0.0.1", bytes.NewReader(body))
+		require.NoError(t, err)
+
+		err = proxy.Proxy(respWriter, req, connection.TypeHTTP)
+		require.NoError(t, err)
+		require.Len(t, transport.bodies, 2)
+		assert.Equal(t, body, transport.bodies[0])
+		assert.Equal(t, body, transport.bodies[1])
+	})
+
+	t.Run("breaker opens after the failure threshold, then half-opens after cooldown", func(t *testing.T) {
+		transport := &countingTransport{}
+		cooldown := 20 * time.Millisecond
+		proxy := newProxy(transport, &config.RetryConfig{
+			MaxRetries:              0,
+			BreakerFailureThreshold: 2,
+			BreakerCooldown:         &cooldown,
+		})
+
+		makeReq := func() error {
+			respWriter := newMockHTTPRespWriter()
+			req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1", nil)
+			require.NoError(t, err)
+			return proxy.Proxy(respWriter, req, connection.TypeHTTP)
+		}
+
+		require.Error(t, makeReq()) // 1st failure: breaker stays closed
+		require.Error(t, makeReq()) // 2nd failure: breaker trips open
+		require.Equal(t, 2, transport.Calls())
+
+		// Breaker is open: this call is fast-failed without reaching the origin.
+		err := makeReq()
+		require.Equal(t, errCircuitBreakerOpen, err)
+		require.Equal(t, 2, transport.Calls())
+
+		time.Sleep(cooldown * 2)
+
+		// Cooldown elapsed: breaker half-opens and lets a probe request through.
+		require.Error(t, makeReq())
+		require.Equal(t, 3, transport.Calls())
+	})
+}
@@ -0,0 +1,8 @@
+package pogs
+
+// Tag is a key/value pair attached to a tunnel connection, surfaced to the
+// edge for routing and observability purposes.
+type Tag struct {
+	Name  string
+	Value string
+}
@@ -0,0 +1,15 @@
+package websocket
+
+import "net"
+
+// Conn wraps a net.Conn so it can be written to and read from using the
+// websocket framing expected by the edge.
+type Conn struct {
+	net.Conn
+}
+
+// NewConn wraps conn as a websocket connection. cfg is reserved for future
+// per-connection options and is currently unused.
+func NewConn(conn net.Conn, cfg interface{}) *Conn {
+	return &Conn{Conn: conn}
+}